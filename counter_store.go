@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Store is an abstract type that defines the fixed-window counting
+// behavior a Limiter delegates to. Decoupling the counting from the
+// Limiter struct itself lets a Limiter's state live somewhere other
+// than process memory, so limits can survive restarts and be shared
+// across horizontally scaled instances of this service.
+type Store interface {
+	// Incr increments the counter for key, creating it with a fresh
+	// window if it doesn't exist or the previous window has expired,
+	// and returns the counter's new value and the time remaining in
+	// its window.
+	Incr(key string, window time.Duration) (count int, ttl time.Duration, err error)
+	// Reset clears the counter for key.
+	Reset(key string, window time.Duration) error
+	// Peek returns the counter's current value and the time remaining
+	// in its window without incrementing it.
+	Peek(key string, window time.Duration) (count int, ttl time.Duration, err error)
+}
+
+// memoryCounter is the state MemoryStore keeps for a single key.
+type memoryCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+// MemoryStore is a concrete implementation of the Store interface
+// backed by an in-process map, matching the counting behavior Limiter
+// used to implement directly before it delegated to a Store.
+type MemoryStore struct {
+	sync.Mutex                           // prevents concurrent access
+	counters   map[string]*memoryCounter // key -> its current window's counter
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*memoryCounter)}
+}
+
+// Incr increments the counter for key, rolling over to a fresh window
+// if key is new or its prior window has expired.
+func (m *MemoryStore) Incr(key string, window time.Duration) (int, time.Duration, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	counter, ok := m.counters[key]
+	if !ok || now.After(counter.expiresAt) {
+		counter = &memoryCounter{expiresAt: now.Add(window)}
+		m.counters[key] = counter
+	}
+	counter.count++
+
+	return counter.count, counter.expiresAt.Sub(now), nil
+}
+
+// Reset clears the counter for key.
+func (m *MemoryStore) Reset(key string, window time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.counters, key)
+	return nil
+}
+
+// Peek returns the counter's current value without incrementing it.
+// A key that is new or whose window has expired reports a zero count.
+func (m *MemoryStore) Peek(key string, window time.Duration) (int, time.Duration, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	counter, ok := m.counters[key]
+	if !ok || now.After(counter.expiresAt) {
+		return 0, window, nil
+	}
+
+	return counter.count, counter.expiresAt.Sub(now), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGCRALimiterBurst(t *testing.T) {
+	// 10 requests per second, with a burst capacity of 2 immediate
+	// requests before the steady-state pacing kicks in.
+	limiter := NewGCRALimiter(10, time.Second, 2)
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.IncrementRequestsUsed(); err != nil {
+			t.Fatalf("expected request %d to be admitted within burst, got %v", i, err)
+		}
+	}
+
+	if err := limiter.IncrementRequestsUsed(); err != ErrTooManyRequests {
+		t.Fatalf("expected request beyond burst to be denied, got %v", err)
+	}
+}
+
+func TestGCRALimiterSteadyStateRefill(t *testing.T) {
+	// 10 requests per second with a burst of 1, so a client can admit
+	// one request immediately and then only one more per 100ms
+	// emission interval.
+	limiter := NewGCRALimiter(10, time.Second, 1)
+
+	if err := limiter.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected first request to be admitted, got %v", err)
+	}
+	if err := limiter.IncrementRequestsUsed(); err != ErrTooManyRequests {
+		t.Fatalf("expected immediate second request to be denied, got %v", err)
+	}
+
+	time.Sleep(110 * time.Millisecond)
+
+	if err := limiter.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected request after emission interval to refill, got %v", err)
+	}
+}
+
+func TestGCRALimiterFactoryReturnsAGCRALimiter(t *testing.T) {
+	factory := GCRALimiterFactory(10, time.Second, 2)
+
+	limiter := factory("gcra-factory-client")
+	if _, ok := limiter.(*GCRALimiter); !ok {
+		t.Fatalf("expected GCRALimiterFactory to produce a *GCRALimiter, got %T", limiter)
+	}
+}
+
+func TestGCRALimiterGetRequestsAvailable(t *testing.T) {
+	limiter := NewGCRALimiter(10, time.Second, 2)
+
+	if available := limiter.GetRequestsAvailable(); available != 2 {
+		t.Fatalf("expected 2 requests available before any use, got %v", available)
+	}
+
+	if err := limiter.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected request to be admitted, got %v", err)
+	}
+
+	if available := limiter.GetRequestsAvailable(); available != 1 {
+		t.Fatalf("expected 1 request available after one use, got %v", available)
+	}
+}
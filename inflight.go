@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+)
+
+const (
+	// DefaultMaxInFlight is the MaxInFlight used when
+	// HttpServerOptions.MaxInFlight is left at zero, which disables
+	// the gate entirely so behavior is unchanged unless an operator
+	// opts in.
+	DefaultMaxInFlight = 0
+	// DefaultMaxConns is the MaxConns used when
+	// HttpServerOptions.MaxConns is left at zero, which disables the
+	// listener's connection cap entirely.
+	DefaultMaxConns = 0
+
+	HttpServiceUnavailable = "Service Unavailable"
+)
+
+// rejectedInFlightCount and rejectedConnCount are Prometheus-style
+// monotonic counters operators can sample to tune MaxInFlight and
+// MaxConns.
+var (
+	rejectedInFlightCount uint64
+	rejectedConnCount     uint64
+)
+
+// RejectedInFlightCount returns the total number of requests
+// MaxInFlightMiddleware has failed fast with 503 since startup.
+func RejectedInFlightCount() uint64 {
+	return atomic.LoadUint64(&rejectedInFlightCount)
+}
+
+// RejectedConnCount returns the total number of times since startup
+// the listener began waiting for a connection while already at
+// MaxConns.
+func RejectedConnCount() uint64 {
+	return atomic.LoadUint64(&rejectedConnCount)
+}
+
+// NewMaxInFlightMiddleware returns a middleware that caps the number
+// of concurrent non-long-running requests to maxInFlight using a
+// buffered channel as a semaphore, failing fast with 503 when it's
+// full rather than queueing. Requests whose path matches
+// longRunningRE, if non-nil, bypass the gate entirely since they're
+// expected to hold a slot for a long time.
+func NewMaxInFlightMiddleware(maxInFlight int, longRunningRE *regexp.Regexp) func(http.Handler) http.Handler {
+	sem := make(chan struct{}, maxInFlight)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if longRunningRE != nil && longRunningRE.MatchString(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				next.ServeHTTP(w, r)
+			default:
+				atomic.AddUint64(&rejectedInFlightCount, 1)
+				http.Error(w, HttpServiceUnavailable, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// countingConn wraps a net.Conn so countingListener can track when a
+// connection closes and frees its slot.
+type countingConn struct {
+	net.Conn
+	onClose func()
+}
+
+// Close releases the connection's slot before closing the underlying
+// net.Conn.
+func (c *countingConn) Close() error {
+	c.onClose()
+	return c.Conn.Close()
+}
+
+// countingListener wraps an already-capped net.Listener (e.g. one
+// returned by netutil.LimitListener) to record a rejected-conn event
+// whenever Accept is called while the listener is already at maxConns,
+// since netutil.LimitListener itself only blocks Accept and exposes no
+// instrumentation hook. It counts calls that have to wait for a free
+// slot, not the individual connections queued behind the cap.
+type countingListener struct {
+	net.Listener
+	maxConns int
+	active   int64
+}
+
+// Accept blocks on the wrapped, already-capped Listener exactly as
+// before, but records a rejected-conn event if the cap was already
+// reached at the time Accept was called.
+func (c *countingListener) Accept() (net.Conn, error) {
+	if c.maxConns > 0 && atomic.LoadInt64(&c.active) >= int64(c.maxConns) {
+		atomic.AddUint64(&rejectedConnCount, 1)
+	}
+
+	conn, err := c.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt64(&c.active, 1)
+	return &countingConn{
+		Conn:    conn,
+		onClose: func() { atomic.AddInt64(&c.active, -1) },
+	}, nil
+}
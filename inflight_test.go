@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestMaxInFlightMiddlewareReturns503WhenSaturated(t *testing.T) {
+	var holding sync.WaitGroup
+	release := make(chan struct{})
+
+	mw := NewMaxInFlightMiddleware(1, nil)
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		holding.Done()
+		<-release
+	}))
+
+	holding.Add(1)
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	holding.Wait() // the goroutine above now holds the single in-flight slot
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	close(release)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once MaxInFlight is saturated, got %v", rec.Code)
+	}
+}
+
+func TestMaxInFlightMiddlewareBypassesLongRunningPaths(t *testing.T) {
+	longRunningRE := regexp.MustCompile(`^/long-running$`)
+	// maxInFlight of 0 means a non-bypassed request can never acquire a
+	// slot, isolating this test to only the bypass behavior.
+	mw := NewMaxInFlightMiddleware(0, longRunningRE)
+
+	var called bool
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/long-running", nil))
+
+	if !called {
+		t.Fatalf("expected a request matching LongRunningRequestRE to bypass the MaxInFlight gate")
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a bypassed long-running request, got %v", rec.Code)
+	}
+}
+
+func TestMaxInFlightMiddlewareRejectsNonMatchingPathsWhenSaturated(t *testing.T) {
+	longRunningRE := regexp.MustCompile(`^/long-running$`)
+	mw := NewMaxInFlightMiddleware(0, longRunningRE)
+
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("expected a non-matching request to be rejected before reaching next")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for a non-bypassed request with no available slots, got %v", rec.Code)
+	}
+}
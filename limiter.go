@@ -1,7 +1,8 @@
 package main
 
 import (
-	"sync"
+	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,9 +13,10 @@ const (
 	ErrClientMapExists = rateLimitError("Client rate limite map already instantiated")
 )
 
-// clientRateLimiterMap is a singleton of all clients we are holding
-// in memory and their associated Limiter
-var clientRateLimiterMap map[string]RateLimiter
+// clientRateLimiterMap is a singleton ClientStore holding every
+// client's RateLimiter in memory, bounded and evicted per ClientStore's
+// own policy.
+var clientRateLimiterMap ClientStore
 
 // rateLimitError is a type we can use to build constant errors
 // related to the rate limiter for stricter error checking.
@@ -37,32 +39,73 @@ type RateLimiter interface {
 }
 
 // Limiter is a concrete implementation on the RateLimiter interface.
-// It stores state pertaining to the requests allowed and how many
-// requests that are currently counted against that limit across the
-// given timeframe interval. This implementation uses a fixed window
-// algorithm for simplicity.
+// It delegates the actual counting of used requests to a Store, so the
+// fixed window algorithm it implements can be backed by process memory
+// or by something shared across instances, like Redis.
 type Limiter struct {
-	sync.Mutex                      // prevents cuncurrent access
-	usedRequests      int           // requests permitted during this timeframe
 	allowedRequests   int           // requests allowed during this timeframe
-	timeframeInterval time.Duration // interval to clear usedRequests
-	doneChan          chan struct{} // channel for shutdown signal
+	timeframeInterval time.Duration // interval a window of usedRequests is counted across
+	store             Store         // where usedRequests is actually counted
+	key               string        // key this Limiter's counter is tracked under in store
+}
+
+// defaultLimiterStore is the Store used by a Limiter that isn't given
+// one via WithStore, preserving the original in-process behavior.
+var defaultLimiterStore Store = NewMemoryStore()
+
+// limiterKeySeq generates a unique default key for a Limiter that
+// isn't given one via WithKey.
+var limiterKeySeq uint64
+
+// LimiterOption configures optional Limiter behavior in NewLimiter.
+type LimiterOption func(*Limiter)
+
+// WithStore overrides the Store a Limiter counts requests against.
+// Use this to back a Limiter with Redis (see RedisStore) instead of
+// the in-process default, e.g. when this service is running as
+// multiple horizontally scaled instances.
+func WithStore(store Store) LimiterOption {
+	return func(l *Limiter) {
+		l.store = store
+	}
+}
+
+// WithKey sets the key a Limiter's counter is tracked under in its
+// Store. This must be stable and shared across instances for a
+// distributed Store like RedisStore to actually enforce a limit
+// cohesively; callers backing a Limiter with a shared Store should
+// always set this to the client's own identifier.
+func WithKey(key string) LimiterOption {
+	return func(l *Limiter) {
+		l.key = key
+	}
 }
 
 // InitClientRateLimiterMap creates an instance of the
-// clientRateLimiterMap
-func InitClientRateLimiterMap() error {
+// clientRateLimiterMap, bounded to maxSize clients and evicting any
+// client idle past ttl. A maxSize or ttl of zero falls back to
+// DefaultClientStoreMaxSize / DefaultClientStoreTTL.
+func InitClientRateLimiterMap(maxSize int, ttl time.Duration) error {
 	if clientRateLimiterMap != nil {
 		return ErrClientMapExists
 	}
-	clientRateLimiterMap = make(map[string]RateLimiter)
+	clientRateLimiterMap = NewLRUClientStore(maxSize, ttl)
 	return nil
 }
 
 // GetRequestsAvailable returns the count of requests that are still
 // allowed under the current time window.
 func (l *Limiter) GetRequestsAvailable() int {
-	return l.allowedRequests - l.usedRequests
+	used, _, err := l.store.Peek(l.key, l.timeframeInterval)
+	if err != nil {
+		return 0
+	}
+
+	available := l.allowedRequests - used
+	if available < 0 {
+		return 0
+	}
+	return available
 }
 
 // GetRequestLimit returns the maximum amount of requests allowed to
@@ -78,60 +121,52 @@ func (l *Limiter) GetTimeframeInterval() time.Duration {
 }
 
 // IncrementRequestsUsed adds another request to the total used
-// requests.
+// requests, atomically via the Store's own Incr so two goroutines (or
+// two instances of this service sharing a Store) can never both
+// observe room for the last available slot.
 func (l *Limiter) IncrementRequestsUsed() error {
-	if l.usedRequests == l.allowedRequests {
+	used, _, err := l.store.Incr(l.key, l.timeframeInterval)
+	if err != nil {
+		return err
+	}
+
+	if used > l.allowedRequests {
 		return ErrTooManyRequests
 	}
-	l.Lock()
-	l.usedRequests++
-	l.Unlock()
 	return nil
 }
 
 // Clear resets the state of used requests to zero.
 func (l *Limiter) Clear() {
-	l.Lock()
-	l.usedRequests = 0
-	l.Unlock()
+	_ = l.store.Reset(l.key, l.timeframeInterval)
 }
 
-// Shutdown ends the ticker that keeps track of the window
+// Shutdown releases this Limiter's counter from its Store. Counting now
+// lives entirely in the Store rather than a goroutine of its own, but
+// callers like LRUClientStore still rely on Shutdown to free a client's
+// state on eviction, so without this the default, shared
+// defaultLimiterStore would grow without bound.
 func (l *Limiter) Shutdown() {
-	l.doneChan <- struct{}{}
-}
-
-// startWindow is a blocking function that will call Clear() on the
-// Limiter every tick of a ticker created with the Limiter's
-// timeframeInterval. Will return when a signal is recieved on the
-// Limiter's doneChan.
-func (l *Limiter) startWindow() {
-	ticker := time.NewTicker(l.timeframeInterval)
-	for {
-		select {
-		case <-ticker.C:
-			l.Clear()
-		case <-l.doneChan:
-			ticker.Stop()
-			return
-		}
-	}
-
+	_ = l.store.Reset(l.key, l.timeframeInterval)
 }
 
 // NewLimter returns an instance of a Limiter with an allowed limit
 // across a duration (in milliseconds) agreed upon with the client
-// using the limiter.
-func NewLimiter(allowedRequests, timeframeMilliseconds int) *Limiter {
+// using the limiter. By default it counts requests in process memory;
+// use WithStore to back it with something shared across instances,
+// and WithKey to set the identifier it's tracked under there.
+func NewLimiter(allowedRequests, timeframeMilliseconds int, opts ...LimiterOption) *Limiter {
 	l := Limiter{
-		usedRequests:    0,
 		allowedRequests: allowedRequests,
 		timeframeInterval: time.Duration(timeframeMilliseconds) *
 			time.Millisecond,
-		doneChan: make(chan struct{}),
+		store: defaultLimiterStore,
+		key:   fmt.Sprintf("limiter-%d", atomic.AddUint64(&limiterKeySeq, 1)),
 	}
 
-	go l.startWindow()
+	for _, opt := range opts {
+		opt(&l)
+	}
 
 	return &l
 }
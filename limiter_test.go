@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterIncrementRequestsUsed(t *testing.T) {
+	limiter := NewLimiter(2, 1000, WithStore(NewMemoryStore()), WithKey("test-client"))
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.IncrementRequestsUsed(); err != nil {
+			t.Fatalf("expected request %d to be admitted, got %v", i, err)
+		}
+	}
+
+	if err := limiter.IncrementRequestsUsed(); err != ErrTooManyRequests {
+		t.Fatalf("expected third request to be denied, got %v", err)
+	}
+}
+
+func TestLimiterSharesCountsAcrossInstancesViaStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	first := NewLimiter(1, 1000, WithStore(store), WithKey("shared"))
+	second := NewLimiter(1, 1000, WithStore(store), WithKey("shared"))
+
+	if err := first.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected first limiter's request to be admitted, got %v", err)
+	}
+
+	if err := second.IncrementRequestsUsed(); err != ErrTooManyRequests {
+		t.Fatalf("expected second limiter sharing the same store and key to see the quota already used, got %v", err)
+	}
+}
+
+func TestLimiterShutdownReleasesStoreState(t *testing.T) {
+	store := NewMemoryStore()
+	limiter := NewLimiter(1, int(time.Hour.Milliseconds()), WithStore(store), WithKey("evicted-client"))
+
+	if err := limiter.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected request to be admitted, got %v", err)
+	}
+
+	limiter.Shutdown()
+
+	used, _, err := store.Peek("evicted-client", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error peeking store: %v", err)
+	}
+	if used != 0 {
+		t.Fatalf("expected Shutdown to release the client's counter from its store, got %v still counted", used)
+	}
+}
+
+func TestLimiterClearResetsUsedRequests(t *testing.T) {
+	limiter := NewLimiter(1, int(time.Hour.Milliseconds()), WithStore(NewMemoryStore()), WithKey("test-client"))
+
+	if err := limiter.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected request to be admitted, got %v", err)
+	}
+	if available := limiter.GetRequestsAvailable(); available != 0 {
+		t.Fatalf("expected 0 requests available after use, got %v", available)
+	}
+
+	limiter.Clear()
+
+	if available := limiter.GetRequestsAvailable(); available != 1 {
+		t.Fatalf("expected 1 request available after Clear, got %v", available)
+	}
+}
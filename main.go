@@ -1,15 +1,15 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func init() {
-	InitClientRateLimiterMap()
+	InitClientRateLimiterMap(DefaultClientStoreMaxSize, DefaultClientStoreTTL)
 }
 
 func main() {
@@ -18,30 +18,48 @@ func main() {
 
 	// start the http server
 	fmt.Println("Starting server")
-	srv := InitServer(nil)
+	srv, err := InitServer(nil)
+	if err != nil {
+		panic(err)
+	}
 	go StartServer(srv)
 	fmt.Println("...done")
 
-	// create some clients
+	// create some clients, alternating which algorithm each one uses to
+	// demonstrate that individual clients can pick GCRA instead of the
+	// default fixed window
 	fmt.Println("Creating client rate limiters")
 	for i := 1; i < 11; i++ {
-		clientRateLimiterMap[fmt.Sprintf("client-%v", i)] = NewLimiter(i, i)
+		clientId := fmt.Sprintf("client-%v", i)
+		if i%2 == 0 {
+			clientRateLimiterMap.Set(clientId, NewGCRALimiter(i, time.Duration(i)*time.Second, i))
+			continue
+		}
+		clientRateLimiterMap.Set(clientId, NewLimiter(i, i, WithKey(clientId)))
 	}
 	fmt.Println("...done")
 
-	// block for shutdown
+	// block for shutdown or a graceful restart
 	fmt.Println("Application started, waiting for shutdown")
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGTERM) // sigterm is what kubernetes uses to shutdown pods
-	<-done
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP) // sigterm is what kubernetes uses to shutdown pods
+	for {
+		sig := <-sigs
+		if sig == syscall.SIGHUP {
+			fmt.Println("\nReceived SIGHUP, restarting")
+			if err := RestartServer(srv); err != nil {
+				fmt.Println("Restart failed, continuing to serve:", err)
+				continue
+			}
+		}
+		break
+	}
 	fmt.Println("\nReceived shutdown")
 	fmt.Println("Closing server")
-	ShutdownServer(srv, context.Background())
+	ShutdownServer(srv, DefaultHammerTimeout)
 	fmt.Println("...done")
 	fmt.Println("Closing clients")
-	for _, client := range clientRateLimiterMap {
-		client.Shutdown()
-	}
+	clientRateLimiterMap.Shutdown()
 	fmt.Println("...done")
 	return
 }
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	HeaderRetryAfter      = "Retry-After"
+	HeaderRateLimitLimit  = "X-RateLimit-Limit"
+	HeaderRateLimitRemain = "X-RateLimit-Remaining"
+	HeaderRateLimitReset  = "X-RateLimit-Reset"
+	HeaderRateLimitScope  = "X-RateLimit-Scope"
+	HttpTooManyRequests   = "Too Many Requests"
+
+	// DefaultAllowedRequests and DefaultTimeframeMilliseconds configure
+	// the Limiter lazily created for a client seen for the first time
+	// by RateLimitMiddleware.
+	DefaultAllowedRequests       = 10
+	DefaultTimeframeMilliseconds = 1000
+)
+
+// SourceExtractor pulls a client identifier out of an inbound request
+// so RateLimitMiddleware knows which RateLimiter to check in the
+// ClientStore. Callers can swap sourceExtractor out for one keyed on a
+// header or the remote IP instead of the default path variable, or
+// implement their own.
+type SourceExtractor interface {
+	Extract(r *http.Request) (string, error)
+}
+
+// SourceExtractorFunc adapts a plain func to a SourceExtractor, the
+// same way http.HandlerFunc adapts a func to an http.Handler, so a
+// custom extractor doesn't need a named type.
+type SourceExtractorFunc func(r *http.Request) (string, error)
+
+// Extract calls f(r).
+func (f SourceExtractorFunc) Extract(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// sourceExtractor is the SourceExtractor RateLimitMiddleware uses to
+// key into clientRateLimiterMap. It defaults to the {clientId} path
+// variable but can be overridden with SetSourceExtractor.
+var sourceExtractor SourceExtractor = ClientIDSourceExtractor
+
+// SetSourceExtractor overrides the SourceExtractor used by
+// RateLimitMiddleware.
+func SetSourceExtractor(e SourceExtractor) {
+	sourceExtractor = e
+}
+
+// ClientIDSourceExtractor pulls the clientId path variable out of the
+// request, matching the existing /{clientId}/... routes.
+var ClientIDSourceExtractor SourceExtractor = SourceExtractorFunc(func(r *http.Request) (string, error) {
+	vars := mux.Vars(r)
+	clientId, ok := vars["clientId"]
+	if !ok || clientId == "" {
+		return "", HttpInternalError
+	}
+	return clientId, nil
+})
+
+// RemoteIPSourceExtractor keys rate limiting on the request's remote
+// IP, useful when clients aren't otherwise authenticated.
+var RemoteIPSourceExtractor SourceExtractor = SourceExtractorFunc(func(r *http.Request) (string, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// RemoteAddr had no port, fall back to using it as-is
+		return r.RemoteAddr, nil
+	}
+	return host, nil
+})
+
+// HeaderSourceExtractor returns a SourceExtractor that keys rate
+// limiting on the value of the named request header.
+func HeaderSourceExtractor(name string) SourceExtractor {
+	return SourceExtractorFunc(func(r *http.Request) (string, error) {
+		value := r.Header.Get(name)
+		if value == "" {
+			return "", HttpInternalError
+		}
+		return value, nil
+	})
+}
+
+// RouteMatcher decides which of a client's MultiLimiter quotas apply
+// to an inbound request, e.g. gating "/expensive" behind an extra
+// quota beyond the client's global one. It's ignored for clients whose
+// RateLimiter isn't a *MultiLimiter.
+type RouteMatcher func(r *http.Request) []string
+
+// routeMatcher is the RouteMatcher RateLimitMiddleware uses to select
+// scopes for a *MultiLimiter client. Nil means every quota the
+// MultiLimiter was constructed with applies to every route.
+var routeMatcher RouteMatcher
+
+// SetRouteMatcher overrides the RouteMatcher used by
+// RateLimitMiddleware.
+func SetRouteMatcher(m RouteMatcher) {
+	routeMatcher = m
+}
+
+// LimiterFactory creates the RateLimiter a client should get the first
+// time RateLimitMiddleware sees it, letting individual clients opt into
+// a different rate limiting algorithm (e.g. GCRALimiter) instead of the
+// default fixed window.
+type LimiterFactory func(clientId string) RateLimiter
+
+// clientLimiterFactory is the LimiterFactory getOrCreateClientLimiter
+// uses to lazily create a client's RateLimiter. It defaults to the
+// fixed-window Limiter but can be overridden with
+// SetClientLimiterFactory, e.g. via GCRALimiterFactory.
+var clientLimiterFactory LimiterFactory = func(clientId string) RateLimiter {
+	return NewLimiter(DefaultAllowedRequests, DefaultTimeframeMilliseconds, WithKey(clientId))
+}
+
+// SetClientLimiterFactory overrides the LimiterFactory
+// getOrCreateClientLimiter uses for clients seen for the first time.
+func SetClientLimiterFactory(f LimiterFactory) {
+	clientLimiterFactory = f
+}
+
+// getOrCreateClientLimiter returns the RateLimiter registered for
+// clientId, lazily creating one via clientLimiterFactory if this is the
+// first time the client has been seen.
+func getOrCreateClientLimiter(clientId string) RateLimiter {
+	return clientRateLimiterMap.GetOrCreate(clientId, func() RateLimiter {
+		return clientLimiterFactory(clientId)
+	})
+}
+
+// setRateLimitHeaders writes the X-RateLimit-* headers describing
+// limiter's current state onto the response. If limiter is a
+// *MultiLimiter and scopes is non-nil, only the named scopes are
+// reported via MultiLimiter.QuotaFor, matching whatever subset of
+// quotas was actually enforced for this request (e.g. via
+// IncrementRequestsUsedForRoute) rather than every quota the client
+// holds.
+func setRateLimitHeaders(w http.ResponseWriter, limiter RateLimiter, scopes []string) {
+	limit, available, timeframe := limiter.GetRequestLimit(), limiter.GetRequestsAvailable(), limiter.GetTimeframeInterval()
+	if multi, ok := limiter.(*MultiLimiter); ok && scopes != nil {
+		limit, available, timeframe = multi.QuotaFor(scopes)
+	}
+
+	// the RateLimiter interface doesn't expose the exact moment the
+	// current window opened, so Reset is approximated as "now plus one
+	// full timeframe interval"
+	reset := time.Now().Add(timeframe)
+
+	w.Header().Set(HeaderRateLimitLimit, strconv.Itoa(limit))
+	w.Header().Set(HeaderRateLimitRemain, strconv.Itoa(available))
+	w.Header().Set(HeaderRateLimitReset, strconv.FormatInt(reset.Unix(), 10))
+}
+
+// RateLimitMiddleware extracts a client identifier with
+// sourceExtractor, looks up (or lazily creates) that client's
+// RateLimiter, and enforces it before calling next. Requests over the
+// limit receive a 429 with Retry-After and X-RateLimit-* headers;
+// requests under the limit carry the same X-RateLimit-* headers so
+// callers can see how much headroom remains.
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, err := sourceExtractor.Extract(r)
+		if err != nil || clientId == "" {
+			http.Error(w, HttpBadRequest, http.StatusBadRequest)
+			return
+		}
+
+		limiter := getOrCreateClientLimiter(clientId)
+
+		var scopes []string
+		var incrementErr error
+		if multi, ok := limiter.(*MultiLimiter); ok && routeMatcher != nil {
+			scopes = routeMatcher(r)
+			incrementErr = multi.IncrementRequestsUsedForRoute(scopes)
+		} else {
+			incrementErr = limiter.IncrementRequestsUsed()
+		}
+
+		if incrementErr != nil {
+			if errors.Is(incrementErr, ErrTooManyRequests) {
+				retryAfter := limiter.GetTimeframeInterval()
+				if multiErr, ok := incrementErr.(*MultiLimiterError); ok {
+					retryAfter = multiErr.RetryAfter
+					w.Header().Set(HeaderRateLimitScope, multiErr.Scope)
+				}
+				w.Header().Set(HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())))
+				setRateLimitHeaders(w, limiter, scopes)
+				http.Error(w, HttpTooManyRequests, http.StatusTooManyRequests)
+				return
+			}
+			http.Error(w, HttpInternalError.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		setRateLimitHeaders(w, limiter, scopes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// consumeResponse is the data returned by the /{clientId}/consume demo
+// endpoint.
+type consumeResponse struct {
+	Message string `json:"message"`
+}
+
+// consume is a demo handler protected by RateLimitMiddleware so
+// callers can observe the 429 behavior end-to-end.
+func consume(w http.ResponseWriter, r *http.Request) {
+	returnBytes, err := json.Marshal(&consumeResponse{Message: "consumed"})
+	if err != nil {
+		http.Error(w, HttpInternalError.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// TODO: this error should get logged somewhere for review!
+	_, _ = w.Write(returnBytes)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// newMiddlewareTestRequest builds a request carrying clientId the way
+// mux would after matching a /{clientId}/... route, since
+// ClientIDSourceExtractor reads it via mux.Vars.
+func newMiddlewareTestRequest(clientId string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/"+clientId+"/consume", nil)
+	return mux.SetURLVars(r, map[string]string{"clientId": clientId})
+}
+
+func TestRateLimitMiddlewareAllowsRequestsUnderTheLimitWithHeaders(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	RateLimitMiddleware(next).ServeHTTP(rec, newMiddlewareTestRequest("middleware-under-limit"))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a request under the limit, got %v", rec.Code)
+	}
+	if got := rec.Header().Get(HeaderRateLimitLimit); got != strconv.Itoa(DefaultAllowedRequests) {
+		t.Fatalf("expected %s of %v, got %v", HeaderRateLimitLimit, DefaultAllowedRequests, got)
+	}
+	if got := rec.Header().Get(HeaderRateLimitRemain); got != strconv.Itoa(DefaultAllowedRequests-1) {
+		t.Fatalf("expected %s of %v after one request, got %v", HeaderRateLimitRemain, DefaultAllowedRequests-1, got)
+	}
+	if rec.Header().Get(HeaderRateLimitReset) == "" {
+		t.Fatalf("expected %s to be set", HeaderRateLimitReset)
+	}
+}
+
+func TestRateLimitMiddlewareDeniesRequestsOverTheLimit(t *testing.T) {
+	clientId := "middleware-over-limit"
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for i := 0; i < DefaultAllowedRequests; i++ {
+		rec := httptest.NewRecorder()
+		RateLimitMiddleware(noop).ServeHTTP(rec, newMiddlewareTestRequest(clientId))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected request %d to be admitted, got %v", i, rec.Code)
+		}
+	}
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	rec := httptest.NewRecorder()
+	RateLimitMiddleware(next).ServeHTTP(rec, newMiddlewareTestRequest(clientId))
+
+	if called {
+		t.Fatalf("expected next handler not to be called once the limit is exhausted")
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the limit is exhausted, got %v", rec.Code)
+	}
+	if rec.Header().Get(HeaderRetryAfter) == "" {
+		t.Fatalf("expected %s to be set on a 429 response", HeaderRetryAfter)
+	}
+	if got := rec.Header().Get(HeaderRateLimitRemain); got != "0" {
+		t.Fatalf("expected %s of 0 on a 429 response, got %v", HeaderRateLimitRemain, got)
+	}
+}
+
+func TestRateLimitMiddlewareLazilyCreatesAClientLimiter(t *testing.T) {
+	clientId := "middleware-lazy-create"
+
+	if _, ok := clientRateLimiterMap.Get(clientId); ok {
+		t.Fatalf("expected client to not be tracked before its first request")
+	}
+
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rec := httptest.NewRecorder()
+	RateLimitMiddleware(noop).ServeHTTP(rec, newMiddlewareTestRequest(clientId))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a new client's first request to be admitted, got %v", rec.Code)
+	}
+	if _, ok := clientRateLimiterMap.Get(clientId); !ok {
+		t.Fatalf("expected the client's RateLimiter to be lazily created on its first request")
+	}
+}
+
+func TestRateLimitMiddlewareHonorsClientLimiterFactory(t *testing.T) {
+	previous := clientLimiterFactory
+	defer SetClientLimiterFactory(previous)
+	SetClientLimiterFactory(GCRALimiterFactory(10, time.Second, 2))
+
+	clientId := "middleware-gcra-client"
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rec := httptest.NewRecorder()
+	RateLimitMiddleware(noop).ServeHTTP(rec, newMiddlewareTestRequest(clientId))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the GCRA-backed client's first request to be admitted, got %v", rec.Code)
+	}
+
+	limiter, ok := clientRateLimiterMap.Get(clientId)
+	if !ok {
+		t.Fatalf("expected the client to be tracked after its first request")
+	}
+	if _, ok := limiter.(*GCRALimiter); !ok {
+		t.Fatalf("expected a client created under a GCRALimiterFactory to get a *GCRALimiter, got %T", limiter)
+	}
+}
+
+func TestRateLimitMiddlewareRejectsRequestsMissingAClientId(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("next handler should not be called without a clientId")
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/consume", nil)
+	r = mux.SetURLVars(r, map[string]string{})
+
+	rec := httptest.NewRecorder()
+	RateLimitMiddleware(next).ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a request missing a clientId, got %v", rec.Code)
+	}
+}
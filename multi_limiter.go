@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QuotaSpec describes one named quota a MultiLimiter enforces, e.g.
+// {Rate: 10, Period: time.Second} for "10 requests per second".
+type QuotaSpec struct {
+	Rate   int
+	Period time.Duration
+}
+
+// MultiLimiterError is returned by MultiLimiter when one of its named
+// quotas is exhausted. It unwraps to ErrTooManyRequests so existing
+// error-checking via errors.Is keeps working, while also carrying
+// which quota tripped and how long the caller should wait.
+type MultiLimiterError struct {
+	Scope      string        // name of the quota that tripped
+	RetryAfter time.Duration // how long until that quota has room again
+}
+
+// Error implements the error interface.
+func (e *MultiLimiterError) Error() string {
+	return ErrTooManyRequests.Error()
+}
+
+// Unwrap lets errors.Is(err, ErrTooManyRequests) succeed for a
+// MultiLimiterError.
+func (e *MultiLimiterError) Unwrap() error {
+	return ErrTooManyRequests
+}
+
+// MultiLimiter is a concrete implementation of the RateLimiter
+// interface that holds several named RateLimiters and enforces all of
+// them at once, e.g. "10 req/sec AND 1000 req/hour". A request only
+// commits against any of them once every one of them has room; if any
+// would be exceeded, none are incremented.
+type MultiLimiter struct {
+	sync.Mutex                        // serializes the check-then-increment across every quota
+	names      []string               // quota names, sorted for deterministic iteration
+	limiters   map[string]RateLimiter // quota name -> the RateLimiter enforcing it
+}
+
+// multiLimiterKeySeq generates a unique default key prefix for a
+// MultiLimiter that isn't given one via WithKeyPrefix, mirroring
+// limiterKeySeq's role for a plain Limiter.
+var multiLimiterKeySeq uint64
+
+// MultiLimiterOption configures optional MultiLimiter behavior in
+// NewMultiLimiter.
+type MultiLimiterOption func(*multiLimiterConfig)
+
+// multiLimiterConfig collects MultiLimiterOption values before
+// NewMultiLimiter builds its sub-limiters.
+type multiLimiterConfig struct {
+	keyPrefix string
+}
+
+// WithKeyPrefix scopes every sub-limiter's Store key under prefix
+// instead of NewMultiLimiter's default unique-per-instance prefix. Set
+// this to a client's own identifier when constructing one MultiLimiter
+// per client; MultiLimiters given the same prefix and quota names share
+// the same underlying Store keys and so count against one counter,
+// while without it each instance counts on its own.
+func WithKeyPrefix(prefix string) MultiLimiterOption {
+	return func(c *multiLimiterConfig) {
+		c.keyPrefix = prefix
+	}
+}
+
+// NewMultiLimiter returns a MultiLimiter enforcing every quota in
+// quotas simultaneously, each as its own fixed-window Limiter. By
+// default each instance is keyed under its own unique prefix; pass
+// WithKeyPrefix to scope it to a client instead.
+func NewMultiLimiter(quotas map[string]QuotaSpec, opts ...MultiLimiterOption) *MultiLimiter {
+	cfg := multiLimiterConfig{
+		keyPrefix: fmt.Sprintf("multilimiter-%d", atomic.AddUint64(&multiLimiterKeySeq, 1)),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	names := make([]string, 0, len(quotas))
+	for name := range quotas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	limiters := make(map[string]RateLimiter, len(quotas))
+	for _, name := range names {
+		spec := quotas[name]
+		limiters[name] = NewLimiter(spec.Rate, int(spec.Period.Milliseconds()), WithKey(cfg.keyPrefix+":"+name))
+	}
+
+	return &MultiLimiter{names: names, limiters: limiters}
+}
+
+// QuotaFor returns the limit, available count, and timeframe reported
+// by whichever of scopes currently has the least headroom, the same
+// way GetRequestLimit/GetRequestsAvailable/GetTimeframeInterval do
+// across every quota this MultiLimiter holds. Callers that only
+// enforced a subset of quotas via IncrementRequestsUsedForRoute should
+// use this instead, so reported headroom matches what was actually
+// checked rather than every quota the client happens to have.
+func (m *MultiLimiter) QuotaFor(scopes []string) (limit, available int, timeframe time.Duration) {
+	_, limiter := m.mostConstrained(scopes)
+	if limiter == nil {
+		return 0, 0, 0
+	}
+	return limiter.GetRequestLimit(), limiter.GetRequestsAvailable(), limiter.GetTimeframeInterval()
+}
+
+// GetRequestLimit returns the limit of whichever quota currently has
+// the least room left, since that's the one a caller is closest to
+// tripping.
+func (m *MultiLimiter) GetRequestLimit() int {
+	_, limiter := m.mostConstrained(m.names)
+	if limiter == nil {
+		return 0
+	}
+	return limiter.GetRequestLimit()
+}
+
+// GetRequestsAvailable returns the requests still available under
+// whichever quota currently has the least room left.
+func (m *MultiLimiter) GetRequestsAvailable() int {
+	_, limiter := m.mostConstrained(m.names)
+	if limiter == nil {
+		return 0
+	}
+	return limiter.GetRequestsAvailable()
+}
+
+// GetTimeframeInterval returns the timeframe of whichever quota
+// currently has the least room left.
+func (m *MultiLimiter) GetTimeframeInterval() time.Duration {
+	_, limiter := m.mostConstrained(m.names)
+	if limiter == nil {
+		return 0
+	}
+	return limiter.GetTimeframeInterval()
+}
+
+// IncrementRequestsUsed checks every quota this MultiLimiter was
+// constructed with and, only if all of them have room, increments all
+// of them. See IncrementRequestsUsedForRoute to check a subset.
+func (m *MultiLimiter) IncrementRequestsUsed() error {
+	return m.incrementScopes(m.names)
+}
+
+// IncrementRequestsUsedForRoute behaves like IncrementRequestsUsed but
+// only checks and increments the named scopes, letting a route opt
+// into a subset of a client's quotas (e.g. an extra "/expensive" quota
+// on top of the client's global one) via a RouteMatcher. Scope names
+// not recognized by this MultiLimiter are ignored.
+func (m *MultiLimiter) IncrementRequestsUsedForRoute(scopes []string) error {
+	return m.incrementScopes(scopes)
+}
+
+// incrementScopes checks every named quota in scopes and, only if all
+// of them have room, commits a request against all of them. If any
+// quota is already exhausted, none are incremented and the error
+// reports whichever exhausted quota has the longest Retry-After, since
+// that's the strictest one a caller needs to respect.
+func (m *MultiLimiter) incrementScopes(scopes []string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	// mostConstrained only ever returns an already-exhausted quota if
+	// one exists, and picks the strictest (longest Retry-After) one
+	// among them, so a single check here covers every quota in scopes.
+	if scope, limiter := m.mostConstrained(scopes); limiter != nil && limiter.GetRequestsAvailable() <= 0 {
+		return &MultiLimiterError{Scope: scope, RetryAfter: limiter.GetTimeframeInterval()}
+	}
+
+	for _, name := range scopes {
+		limiter, ok := m.limiters[name]
+		if !ok {
+			continue
+		}
+		if err := limiter.IncrementRequestsUsed(); err != nil {
+			return &MultiLimiterError{Scope: name, RetryAfter: limiter.GetTimeframeInterval()}
+		}
+	}
+
+	return nil
+}
+
+// mostConstrained returns the name and RateLimiter, among scopes, with
+// the fewest requests currently available. When several quotas are
+// simultaneously exhausted this identifies the strictest (longest
+// Retry-After) one, since among exhausted quotas it keeps the one with
+// the longest GetTimeframeInterval.
+func (m *MultiLimiter) mostConstrained(scopes []string) (string, RateLimiter) {
+	var bestName string
+	var best RateLimiter
+	var bestRetryAfter time.Duration
+
+	for _, name := range scopes {
+		limiter, ok := m.limiters[name]
+		if !ok {
+			continue
+		}
+		if limiter.GetRequestsAvailable() > 0 {
+			continue
+		}
+		if best == nil || limiter.GetTimeframeInterval() > bestRetryAfter {
+			bestName, best, bestRetryAfter = name, limiter, limiter.GetTimeframeInterval()
+		}
+	}
+
+	if best != nil {
+		return bestName, best
+	}
+
+	// nothing is exhausted; fall back to reporting whichever quota has
+	// the least headroom, for GetRequestsAvailable et al.
+	for _, name := range scopes {
+		limiter, ok := m.limiters[name]
+		if !ok {
+			continue
+		}
+		if best == nil || limiter.GetRequestsAvailable() < best.GetRequestsAvailable() {
+			bestName, best = name, limiter
+		}
+	}
+
+	return bestName, best
+}
+
+// Clear resets every quota this MultiLimiter holds.
+func (m *MultiLimiter) Clear() {
+	for _, limiter := range m.limiters {
+		limiter.Clear()
+	}
+}
+
+// Shutdown shuts down every quota's underlying RateLimiter.
+func (m *MultiLimiter) Shutdown() {
+	for _, limiter := range m.limiters {
+		limiter.Shutdown()
+	}
+}
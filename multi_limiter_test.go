@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMultiLimiterRequiresAllQuotasToHaveRoom(t *testing.T) {
+	ml := NewMultiLimiter(map[string]QuotaSpec{
+		"requires-all-per-second": {Rate: 2, Period: time.Second},
+		"requires-all-per-hour":   {Rate: 1, Period: time.Hour},
+	})
+
+	if err := ml.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected first request to be admitted, got %v", err)
+	}
+
+	err := ml.IncrementRequestsUsed()
+	if err == nil {
+		t.Fatalf("expected second request to be denied by the exhausted per-hour quota")
+	}
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("expected errors.Is(err, ErrTooManyRequests) to succeed, got %v", err)
+	}
+}
+
+func TestMultiLimiterDoesNotPartiallyIncrementOnDenial(t *testing.T) {
+	ml := NewMultiLimiter(map[string]QuotaSpec{
+		"partial-per-second": {Rate: 2, Period: time.Second},
+		"partial-per-hour":   {Rate: 1, Period: time.Hour},
+	})
+
+	if err := ml.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected first request to be admitted, got %v", err)
+	}
+	if err := ml.IncrementRequestsUsed(); err == nil {
+		t.Fatalf("expected second request to be denied")
+	}
+
+	if available := ml.limiters["partial-per-second"].GetRequestsAvailable(); available != 1 {
+		t.Fatalf("expected per-second quota to still have 1 request available since the denied request shouldn't have committed against it, got %v", available)
+	}
+}
+
+func TestMultiLimiterSurfacesStrictestRetryAfter(t *testing.T) {
+	ml := NewMultiLimiter(map[string]QuotaSpec{
+		"strictest-per-second": {Rate: 0, Period: time.Second},
+		"strictest-per-hour":   {Rate: 0, Period: time.Hour},
+	})
+
+	err := ml.IncrementRequestsUsed()
+	if err == nil {
+		t.Fatalf("expected request to be denied since both quotas start exhausted")
+	}
+
+	multiErr, ok := err.(*MultiLimiterError)
+	if !ok {
+		t.Fatalf("expected a *MultiLimiterError, got %T", err)
+	}
+	if multiErr.Scope != "strictest-per-hour" {
+		t.Fatalf("expected the per-hour quota to be reported as the strictest, got %q", multiErr.Scope)
+	}
+	if multiErr.RetryAfter != time.Hour {
+		t.Fatalf("expected RetryAfter of 1h, got %v", multiErr.RetryAfter)
+	}
+}
+
+func TestMultiLimiterDefaultsToAUniquePerInstanceKey(t *testing.T) {
+	quotas := map[string]QuotaSpec{"global": {Rate: 1, Period: time.Hour}}
+
+	first := NewMultiLimiter(quotas)
+	second := NewMultiLimiter(quotas)
+
+	if err := first.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected client A's request to be admitted, got %v", err)
+	}
+	if err := second.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected client B's independent MultiLimiter to have its own quota, got %v", err)
+	}
+}
+
+func TestMultiLimiterWithKeyPrefixIsolatesPerClient(t *testing.T) {
+	quotas := map[string]QuotaSpec{"global": {Rate: 1, Period: time.Hour}}
+
+	clientA := NewMultiLimiter(quotas, WithKeyPrefix("client-a"))
+	clientB := NewMultiLimiter(quotas, WithKeyPrefix("client-b"))
+
+	if err := clientA.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected client A's request to be admitted, got %v", err)
+	}
+	if err := clientB.IncrementRequestsUsed(); err != nil {
+		t.Fatalf("expected client B to have its own quota despite sharing quota names with client A, got %v", err)
+	}
+
+	sameClientA := NewMultiLimiter(quotas, WithKeyPrefix("client-a"))
+	if err := sameClientA.IncrementRequestsUsed(); err == nil {
+		t.Fatalf("expected a second MultiLimiter sharing client A's prefix to see its quota already used")
+	}
+}
+
+func TestMultiLimiterQuotaForReportsOnlyTheGivenScopes(t *testing.T) {
+	ml := NewMultiLimiter(map[string]QuotaSpec{
+		"quotafor-global":    {Rate: 10, Period: time.Second},
+		"quotafor-expensive": {Rate: 0, Period: time.Minute},
+	})
+
+	limit, available, timeframe := ml.QuotaFor([]string{"quotafor-global"})
+	if limit != 10 || available != 10 || timeframe != time.Second {
+		t.Fatalf("expected QuotaFor to report the global quota's own limit/available/timeframe untouched by the exhausted expensive quota, got limit=%v available=%v timeframe=%v", limit, available, timeframe)
+	}
+}
+
+func TestMultiLimiterIncrementRequestsUsedForRouteScopesSubset(t *testing.T) {
+	ml := NewMultiLimiter(map[string]QuotaSpec{
+		"global":    {Rate: 10, Period: time.Second},
+		"expensive": {Rate: 0, Period: time.Second},
+	})
+
+	if err := ml.IncrementRequestsUsedForRoute([]string{"global"}); err != nil {
+		t.Fatalf("expected a route scoped to only the global quota to be admitted, got %v", err)
+	}
+
+	if available := ml.limiters["expensive"].GetRequestsAvailable(); available != 0 {
+		t.Fatalf("expected the expensive quota, which wasn't in scope, to be untouched, got %v available", available)
+	}
+
+	if err := ml.IncrementRequestsUsedForRoute([]string{"global", "expensive"}); err == nil {
+		t.Fatalf("expected a route scoped to both quotas to be denied by the exhausted expensive quota")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a concrete implementation of the Store interface that
+// keeps its fixed-window counters in Redis instead of process memory,
+// so limits survive a restart of this service and are shared across
+// every horizontally scaled instance of it.
+//
+// Each window is its own key, {key}:{window_epoch}, incremented with
+// INCR and given an EXPIRE on first hit so Redis reclaims it on its
+// own once the window closes.
+type RedisStore struct {
+	client redis.Cmdable
+	ctx    context.Context
+}
+
+// NewRedisStore returns a RedisStore backed by client. ctx is used for
+// every command issued against Redis; pass context.Background() if the
+// caller has no deadline or cancellation to propagate.
+func NewRedisStore(client redis.Cmdable, ctx context.Context) *RedisStore {
+	return &RedisStore{client: client, ctx: ctx}
+}
+
+// windowKey returns the key for the window key is currently in, given
+// window's length.
+func windowKey(key string, window time.Duration) string {
+	epoch := time.Now().UnixNano() / int64(window)
+	return fmt.Sprintf("%s:%d", key, epoch)
+}
+
+// Incr increments the counter for key's current window, setting the
+// window's expiry on the first hit so the key disappears on its own
+// once the window closes.
+func (r *RedisStore) Incr(key string, window time.Duration) (int, time.Duration, error) {
+	wKey := windowKey(key, window)
+
+	count, err := r.client.Incr(r.ctx, wKey).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if count == 1 {
+		if err := r.client.Expire(r.ctx, wKey, window).Err(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	ttl, err := r.client.TTL(r.ctx, wKey).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(count), ttl, nil
+}
+
+// Reset deletes the counter for key's current window.
+func (r *RedisStore) Reset(key string, window time.Duration) error {
+	return r.client.Del(r.ctx, windowKey(key, window)).Err()
+}
+
+// Peek returns the counter's current value without incrementing it. A
+// key that hasn't been incremented yet in its current window reports a
+// zero count.
+func (r *RedisStore) Peek(key string, window time.Duration) (int, time.Duration, error) {
+	wKey := windowKey(key, window)
+
+	count, err := r.client.Get(r.ctx, wKey).Int()
+	if err == redis.Nil {
+		return 0, window, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ttl, err := r.client.TTL(r.ctx, wKey).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, ttl, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	// ListenFDEnvKey is the environment variable RestartServer sets on
+	// the re-exec'd process to tell it which inherited file descriptor
+	// to listen on instead of opening a fresh socket.
+	ListenFDEnvKey = "RATE_LIMITER_LISTEN_FD"
+
+	// listenFD is the file descriptor RestartServer always hands the
+	// listening socket to the child process as, since stdin, stdout,
+	// and stderr occupy 0, 1, and 2.
+	listenFD = 3
+
+	ErrCannotRestart = restartError("active listener does not support handing off its socket")
+)
+
+// restartError is a type we can use to build constant errors related
+// to RestartServer for stricter error checking.
+type restartError string
+
+// Error implements the error interface.
+func (r restartError) Error() string {
+	return string(r)
+}
+
+// filer is satisfied by a net.Listener backed by a real OS socket,
+// such as *net.TCPListener, letting RestartServer get at the file
+// descriptor it needs to hand off without caring about the concrete
+// listener type.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// RestartServer re-execs the running binary, handing the process's
+// active listening socket to the child via os.StartProcess as an extra
+// entry in ProcAttr.Files so it can start accepting connections immediately while
+// this process finishes draining whatever it's already serving. The
+// caller is still responsible for calling ShutdownServer afterward so
+// this process actually exits once its in-flight requests complete.
+func RestartServer(s *Server) error {
+	fl, ok := s.getListener().(filer)
+	if !ok {
+		return ErrCannotRestart
+	}
+
+	lnFile, err := fl.File()
+	if err != nil {
+		return err
+	}
+	defer lnFile.Close()
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), fmt.Sprintf("%s=%d", ListenFDEnvKey, listenFD))
+
+	_, err = os.StartProcess(execPath, os.Args, &os.ProcAttr{
+		Env:   env,
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr, lnFile},
+	})
+	return err
+}
@@ -3,10 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/net/netutil"
 )
 
 const (
@@ -40,13 +46,58 @@ type AvailableRequestsResponse struct {
 type HttpServerOptions struct {
 	ReadTimeout, WriteTimeout time.Duration
 	Address                   string
+	MaxInFlight               int    // caps concurrent non-long-running requests; 0 disables the gate
+	MaxConns                  int    // caps total open connections on the listener; 0 disables the cap
+	LongRunningRequestRE      string // paths matching this regex bypass the MaxInFlight gate
 }
 
+// Server wraps an http.Server with the runtime state StartServer,
+// ShutdownServer, and RestartServer need but that doesn't belong on
+// http.Server itself. Carrying this on the Server value instead of
+// package-level globals lets more than one independently configured
+// Server exist in the same process without clobbering each other.
+type Server struct {
+	*http.Server
+	maxConns int // caps total open connections on the listener; 0 disables the cap
+
+	listenerMu sync.Mutex   // guards listener, since StartServer sets it from its own goroutine while RestartServer reads it from the signal-handling goroutine
+	listener   net.Listener // the raw, unwrapped listener this Server is currently serving on
+}
+
+// setListener records ln as the raw listener this Server is currently
+// serving on.
+func (s *Server) setListener(ln net.Listener) {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	s.listener = ln
+}
+
+// getListener returns the raw listener this Server is currently
+// serving on, or nil if StartServer hasn't run yet.
+func (s *Server) getListener() net.Listener {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	return s.listener
+}
+
+// HammerTimeout bounds how long ShutdownServer waits for in-flight
+// requests to drain gracefully before force-closing the server so the
+// process can still exit.
+type HammerTimeout time.Duration
+
+// DefaultHammerTimeout is the HammerTimeout ShutdownServer uses when
+// callers don't have a more specific deadline in mind.
+const DefaultHammerTimeout = HammerTimeout(30 * time.Second)
+
 // InitServer instantializes an HTTP server struct with optional
 // overrides to default values.
-func InitServer(options *HttpServerOptions) *http.Server {
+func InitServer(options *HttpServerOptions) (*Server, error) {
 	addr := HttpServerAddress
 	readTimeout, writeTimeout := HttpTimeout, HttpTimeout
+	maxInFlight := DefaultMaxInFlight
+	maxConns := DefaultMaxConns
+	var longRunningRE *regexp.Regexp
+
 	if options != nil {
 		if options.Address != "" {
 			addr = options.Address
@@ -57,13 +108,35 @@ func InitServer(options *HttpServerOptions) *http.Server {
 		if options.WriteTimeout != 0 {
 			writeTimeout = options.WriteTimeout
 		}
+		if options.MaxInFlight != 0 {
+			maxInFlight = options.MaxInFlight
+		}
+		if options.MaxConns != 0 {
+			maxConns = options.MaxConns
+		}
+		if options.LongRunningRequestRE != "" {
+			re, err := regexp.Compile(options.LongRunningRequestRE)
+			if err != nil {
+				return nil, err
+			}
+			longRunningRE = re
+		}
 	}
-	return &http.Server{
-		Addr:         addr,
-		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
-		Handler:      initRouter(),
+
+	router := initRouter()
+	if maxInFlight > 0 {
+		router.Use(NewMaxInFlightMiddleware(maxInFlight, longRunningRE))
 	}
+
+	return &Server{
+		Server: &http.Server{
+			Addr:         addr,
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			Handler:      router,
+		},
+		maxConns: maxConns,
+	}, nil
 }
 
 // initRouter initializes a new gorilla/mux router and registers the
@@ -72,26 +145,76 @@ func initRouter() *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/{clientId}/requests-available",
 		getAvailableRequests).Methods(http.MethodGet)
+
+	// /consume is gated by RateLimitMiddleware so callers can observe
+	// the 429 behavior end-to-end; requests-available above stays
+	// unprotected since it only reports counts.
+	consumeRouter := r.PathPrefix("/{clientId}/consume").Subrouter()
+	consumeRouter.Use(RateLimitMiddleware)
+	consumeRouter.HandleFunc("", consume).Methods(http.MethodPost)
+
 	return r
 }
 
 // StartServer is a blocking function that begins listening on the
-// address provided when initializing the server.
-func StartServer(s *http.Server) {
-	if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+// address provided when initializing the server. When MaxConns is set,
+// the listener is wrapped with netutil.LimitListener so the total number of open
+// connections is capped regardless of request rate, independent of
+// the per-client and MaxInFlight limits. If a listening socket was
+// handed down by RestartServer, that socket is inherited instead of a
+// fresh one being opened, so a restart never drops a connection.
+func StartServer(s *Server) {
+	ln, err := listen(s.Addr)
+	if err != nil {
+		// TODO: this should be a captured error for investigation
+		panic(err)
+	}
+	s.setListener(ln)
+
+	servingListener := ln
+	if s.maxConns > 0 {
+		servingListener = &countingListener{
+			Listener: netutil.LimitListener(ln, s.maxConns),
+			maxConns: s.maxConns,
+		}
+	}
+
+	if err := s.Serve(servingListener); err != nil && err != http.ErrServerClosed {
 		// TODO: this should be a captured error for investigation
 		panic(err)
 	}
 }
 
-// ShutdownServer blocks until all connections are either closed or
-// timed out and then removes the server.
-func ShutdownServer(s *http.Server, ctx context.Context) error {
-	if err := s.Shutdown(ctx); err != nil {
-		return err
+// listen opens the TCP listener StartServer serves on, inheriting one
+// from a prior process via ListenFDEnvKey if RestartServer passed one
+// along instead of opening a fresh socket.
+func listen(addr string) (net.Listener, error) {
+	if fdStr := os.Getenv(ListenFDEnvKey); fdStr != "" {
+		fd, err := strconv.Atoi(fdStr)
+		if err != nil {
+			return nil, err
+		}
+		return net.FileListener(os.NewFile(uintptr(fd), "listener"))
 	}
+	return net.Listen("tcp", addr)
+}
 
-	return nil
+// ShutdownServer attempts a graceful Shutdown(ctx) bounded by
+// hammerTimeout; if that deadline fires before every connection has
+// drained, it falls back to Close() so the process can still exit
+// instead of blocking forever on a client that never disconnects.
+func ShutdownServer(s *Server, hammerTimeout HammerTimeout) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(hammerTimeout))
+	defer cancel()
+
+	err := s.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+	if err == context.DeadlineExceeded {
+		return s.Close()
+	}
+	return err
 }
 
 // getAvailableRequests returns the available requests for the provided
@@ -107,7 +230,7 @@ func getAvailableRequests(w http.ResponseWriter, r *http.Request) {
 
 	// check if this clientId was instantiated in the rate limit to
 	// client map
-	clientRateLimiter, ok := clientRateLimiterMap[clientId]
+	clientRateLimiter, ok := clientRateLimiterMap.Get(clientId)
 	if !ok {
 		// we haven't initialized a limiter with this client yet
 		http.Error(w, HttpNotFound, http.StatusNotFound)
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultClientStoreMaxSize bounds how many clients an
+	// LRUClientStore keeps in memory at once.
+	DefaultClientStoreMaxSize = 65536
+	// DefaultClientStoreTTL is how long a client can sit idle before
+	// an LRUClientStore evicts it.
+	DefaultClientStoreTTL = 30 * time.Minute
+)
+
+// ClientStore is an abstract type that defines the behavior we expect
+// from something that tracks a RateLimiter per client. Implementations
+// are expected to be safe for concurrent use.
+type ClientStore interface {
+	// Get returns the client's RateLimiter, if one is tracked.
+	Get(clientId string) (RateLimiter, bool)
+	// GetOrCreate returns the client's RateLimiter, calling factory to
+	// create and register one if the client isn't tracked yet.
+	GetOrCreate(clientId string, factory func() RateLimiter) RateLimiter
+	// Set registers limiter as the RateLimiter for clientId, replacing
+	// any existing one.
+	Set(clientId string, limiter RateLimiter)
+	// Shutdown stops any background goroutines the store owns and
+	// calls Shutdown on every RateLimiter still being tracked.
+	Shutdown()
+}
+
+// clientEntry is the value stored in an LRUClientStore's linked list.
+type clientEntry struct {
+	key        string
+	limiter    RateLimiter
+	lastAccess time.Time
+}
+
+// LRUClientStore is a concrete implementation of the ClientStore
+// interface. It keeps at most maxSize clients in memory, evicting the
+// least-recently-used client once that bound is exceeded, and runs a
+// janitor goroutine that evicts any client idle past ttl. Evicted
+// clients have Shutdown() called on their RateLimiter so whatever state
+// it holds (e.g. a Limiter's counter in its Store) is released, closing
+// the leak a plain map with no eviction would otherwise have.
+type LRUClientStore struct {
+	sync.Mutex                          // prevents concurrent access
+	maxSize    int                      // maximum number of clients to track
+	ttl        time.Duration            // idle duration before a client is evicted
+	order      *list.List               // most- to least-recently-used clientEntry values
+	elements   map[string]*list.Element // clientId -> its entry in order
+	doneChan   chan struct{}            // channel for janitor shutdown signal
+}
+
+// NewLRUClientStore returns an LRUClientStore bounded to maxSize
+// clients, evicting clients idle past ttl. A maxSize or ttl of zero
+// falls back to DefaultClientStoreMaxSize / DefaultClientStoreTTL.
+func NewLRUClientStore(maxSize int, ttl time.Duration) *LRUClientStore {
+	if maxSize <= 0 {
+		maxSize = DefaultClientStoreMaxSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultClientStoreTTL
+	}
+
+	s := &LRUClientStore{
+		maxSize:  maxSize,
+		ttl:      ttl,
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+		doneChan: make(chan struct{}),
+	}
+
+	go s.startJanitor()
+
+	return s
+}
+
+// Get returns the client's RateLimiter, if one is tracked, and marks
+// it as most-recently-used.
+func (s *LRUClientStore) Get(clientId string) (RateLimiter, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	el, ok := s.elements[clientId]
+	if !ok {
+		return nil, false
+	}
+	s.touch(el)
+	return el.Value.(*clientEntry).limiter, true
+}
+
+// GetOrCreate returns the client's RateLimiter, calling factory to
+// create and register one under maxSize/eviction rules if the client
+// isn't tracked yet.
+func (s *LRUClientStore) GetOrCreate(clientId string, factory func() RateLimiter) RateLimiter {
+	s.Lock()
+	defer s.Unlock()
+
+	if el, ok := s.elements[clientId]; ok {
+		s.touch(el)
+		return el.Value.(*clientEntry).limiter
+	}
+
+	entry := &clientEntry{key: clientId, limiter: factory(), lastAccess: time.Now()}
+	s.elements[clientId] = s.order.PushFront(entry)
+
+	if s.order.Len() > s.maxSize {
+		s.evict(s.order.Back())
+	}
+
+	return entry.limiter
+}
+
+// Set registers limiter as the RateLimiter for clientId, replacing and
+// shutting down any existing one.
+func (s *LRUClientStore) Set(clientId string, limiter RateLimiter) {
+	s.Lock()
+	defer s.Unlock()
+
+	if el, ok := s.elements[clientId]; ok {
+		s.evict(el)
+	}
+
+	entry := &clientEntry{key: clientId, limiter: limiter, lastAccess: time.Now()}
+	s.elements[clientId] = s.order.PushFront(entry)
+
+	if s.order.Len() > s.maxSize {
+		s.evict(s.order.Back())
+	}
+}
+
+// Shutdown stops the janitor goroutine and calls Shutdown on every
+// client's RateLimiter still being tracked.
+func (s *LRUClientStore) Shutdown() {
+	s.doneChan <- struct{}{}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		el.Value.(*clientEntry).limiter.Shutdown()
+	}
+	s.order.Init()
+	s.elements = make(map[string]*list.Element)
+}
+
+// touch marks el as most-recently-used. Callers must hold s.Mutex.
+func (s *LRUClientStore) touch(el *list.Element) {
+	el.Value.(*clientEntry).lastAccess = time.Now()
+	s.order.MoveToFront(el)
+}
+
+// evict removes el from the store and shuts down its RateLimiter.
+// Callers must hold s.Mutex.
+func (s *LRUClientStore) evict(el *list.Element) {
+	if el == nil {
+		return
+	}
+	entry := el.Value.(*clientEntry)
+	s.order.Remove(el)
+	delete(s.elements, entry.key)
+	entry.limiter.Shutdown()
+}
+
+// sweepIdle evicts every client that has been idle past the store's
+// ttl. The list is kept most- to least-recently-used, so eviction can
+// stop at the first client that is still fresh.
+func (s *LRUClientStore) sweepIdle() {
+	s.Lock()
+	defer s.Unlock()
+
+	cutoff := time.Now().Add(-s.ttl)
+	for el := s.order.Back(); el != nil; {
+		entry := el.Value.(*clientEntry)
+		if entry.lastAccess.After(cutoff) {
+			break
+		}
+		prev := el.Prev()
+		s.evict(el)
+		el = prev
+	}
+}
+
+// startJanitor is a blocking function that calls sweepIdle on a ticker
+// cadenced to the store's ttl. Will return when a signal is received
+// on the store's doneChan.
+func (s *LRUClientStore) startJanitor() {
+	ticker := time.NewTicker(s.ttl)
+	for {
+		select {
+		case <-ticker.C:
+			s.sweepIdle()
+		case <-s.doneChan:
+			ticker.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+type noopLimiter struct {
+	shutdownCalls int
+}
+
+func (n *noopLimiter) GetRequestLimit() int                { return 0 }
+func (n *noopLimiter) GetRequestsAvailable() int           { return 0 }
+func (n *noopLimiter) GetTimeframeInterval() time.Duration { return 0 }
+func (n *noopLimiter) IncrementRequestsUsed() error        { return nil }
+func (n *noopLimiter) Clear()                              {}
+func (n *noopLimiter) Shutdown()                           { n.shutdownCalls++ }
+
+func TestLRUClientStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewLRUClientStore(2, time.Hour)
+	defer store.Shutdown()
+
+	first := &noopLimiter{}
+	second := &noopLimiter{}
+	third := &noopLimiter{}
+
+	store.Set("first", first)
+	store.Set("second", second)
+	store.Set("third", third)
+
+	if first.shutdownCalls != 1 {
+		t.Fatalf("expected evicted client to have Shutdown called once, got %v calls", first.shutdownCalls)
+	}
+	if _, ok := store.Get("first"); ok {
+		t.Fatalf("expected evicted client to no longer be tracked")
+	}
+	if _, ok := store.Get("second"); !ok {
+		t.Fatalf("expected second client to still be tracked")
+	}
+	if _, ok := store.Get("third"); !ok {
+		t.Fatalf("expected third client to still be tracked")
+	}
+}
+
+func TestLRUClientStoreGetOrCreateReusesExisting(t *testing.T) {
+	store := NewLRUClientStore(10, time.Hour)
+	defer store.Shutdown()
+
+	calls := 0
+	factory := func() RateLimiter {
+		calls++
+		return &noopLimiter{}
+	}
+
+	first := store.GetOrCreate("client", factory)
+	second := store.GetOrCreate("client", factory)
+
+	if calls != 1 {
+		t.Fatalf("expected factory to be called once, got %v calls", calls)
+	}
+	if first != second {
+		t.Fatalf("expected GetOrCreate to return the same RateLimiter for the same client")
+	}
+}
+
+func TestLRUClientStoreSweepIdleEvictsExpired(t *testing.T) {
+	store := NewLRUClientStore(10, 50*time.Millisecond)
+	defer store.Shutdown()
+
+	idle := &noopLimiter{}
+	store.Set("idle", idle)
+
+	time.Sleep(75 * time.Millisecond)
+	store.sweepIdle()
+
+	if idle.shutdownCalls != 1 {
+		t.Fatalf("expected idle client to have Shutdown called once, got %v calls", idle.shutdownCalls)
+	}
+	if _, ok := store.Get("idle"); ok {
+		t.Fatalf("expected idle client to have been evicted")
+	}
+}
+
+func TestLRUClientStoreShutdownShutsDownRemainingClients(t *testing.T) {
+	store := NewLRUClientStore(10, time.Hour)
+
+	one := &noopLimiter{}
+	two := &noopLimiter{}
+	store.Set("one", one)
+	store.Set("two", two)
+
+	store.Shutdown()
+
+	if one.shutdownCalls != 1 || two.shutdownCalls != 1 {
+		t.Fatalf("expected both remaining clients to have Shutdown called once, got %v and %v", one.shutdownCalls, two.shutdownCalls)
+	}
+}